@@ -5,21 +5,228 @@ package wasmexec
 
 import (
 	"errors"
+	"io"
 	"io/fs"
 	"os"
+	"sync"
 	"syscall"
+	"time"
 )
 
 // HostFS describes an instance that has implemented the FS methods.
 type HostFS interface {
 	fs.FS
 	fs.StatFS
-	// fs.ReadDirFS
+	fs.ReadDirFS
 
 	// Chmod changes the mode of a file.
 	Chmod(name string, mode fs.FileMode) error
 }
 
+// HostWriteFS extends HostFS with the write-side operations needed to back
+// a guest Go program compiled with GOOS=js that does more than read files.
+type HostWriteFS interface {
+	HostFS
+
+	// OpenFile opens the named file with the given flags and permissions,
+	// returning a handle that supports the remaining fd-based calls below.
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+
+	// Mkdir creates a new directory with the given permissions.
+	Mkdir(name string, perm fs.FileMode) error
+
+	// Remove removes the named file or (empty) directory.
+	Remove(name string) error
+
+	// Rename renames (moves) oldpath to newpath.
+	Rename(oldpath, newpath string) error
+
+	// Truncate changes the size of the named file.
+	Truncate(name string, size int64) error
+
+	// Chtimes changes the access and modification times of the named file.
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// HostSymlinkFS is an optional interface a HostFS may implement to support
+// the symlink-aware calls the Go runtime makes on behalf of os.Lstat,
+// os.Symlink, os.Readlink, and os.Link.
+type HostSymlinkFS interface {
+	HostFS
+
+	// Lstat returns file info about name without following a symlink.
+	Lstat(name string) (fs.FileInfo, error)
+
+	// Symlink creates newname as a symbolic link to oldname.
+	Symlink(oldname, newname string) error
+
+	// Readlink returns the destination of the symbolic link at name.
+	Readlink(name string) (string, error)
+
+	// Link creates newname as a hard link to oldname.
+	Link(oldname, newname string) error
+}
+
+// File is a file handle opened through HostWriteFS. It covers what the Go
+// runtime's fs_js.go callbacks expect to do with an open file descriptor.
+type File interface {
+	fs.File
+
+	io.Writer
+	io.Seeker
+
+	// Sync commits the current contents of the file to stable storage.
+	Sync() error
+
+	// Truncate changes the size of the file.
+	Truncate(size int64) error
+
+	// ReadDir reads the contents of the directory and returns up to n
+	// entries, mirroring fs.ReadDirFile.
+	ReadDir(n int) ([]fs.DirEntry, error)
+}
+
+// readOnlyFile adapts an fs.File returned by HostFS.Open so it satisfies
+// File, rejecting any of the write-side operations with EBADF.
+type readOnlyFile struct {
+	fs.File
+}
+
+func (f readOnlyFile) Write([]byte) (int, error) {
+	return 0, syscall.EBADF
+}
+
+func (f readOnlyFile) Seek(offset int64, whence int) (int64, error) {
+	if seeker, ok := f.File.(io.Seeker); ok {
+		return seeker.Seek(offset, whence)
+	}
+	return 0, syscall.EBADF
+}
+
+func (f readOnlyFile) Sync() error {
+	return syscall.EBADF
+}
+
+func (f readOnlyFile) Truncate(int64) error {
+	return syscall.EBADF
+}
+
+func (f readOnlyFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if dir, ok := f.File.(fs.ReadDirFile); ok {
+		return dir.ReadDir(n)
+	}
+	return nil, syscall.ENOTDIR
+}
+
+// openFile tracks the state the fd-based callbacks need for a single open
+// file descriptor: its path (used by fsFchmod and fsReaddir's per-path
+// bookkeeping) and the current offset.
+type openFile struct {
+	path   string
+	file   File
+	offset int64
+
+	// dirRead marks whether fsReaddir has already served this fd its
+	// directory listing; a second call for the same fd gets an empty
+	// listing instead of the full one again. Set by fdTable.claimDirRead.
+	dirRead bool
+}
+
+// fdTable hands out file descriptors and tracks their associated openFiles,
+// mirroring the Go stdlib's own "files" map in syscall/fs_js.go.
+type fdTable struct {
+	mu    sync.Mutex
+	next  int32
+	files map[int32]*openFile
+
+	// dirWaiting tracks, per path, the fds opened against it that haven't
+	// had their first fs.readdir response served yet, oldest first. This
+	// is what lets claimDirRead give two fds concurrently open on the same
+	// path each their own listing instead of racing over a single shared
+	// per-path flag.
+	dirWaiting map[string][]int32
+}
+
+// newFDTable returns an empty fdTable. Descriptors start at 3, leaving 0-2
+// for stdin/stdout/stderr as the Go runtime expects.
+func newFDTable() *fdTable {
+	return &fdTable{
+		next:       3,
+		files:      make(map[int32]*openFile),
+		dirWaiting: make(map[string][]int32),
+	}
+}
+
+func (t *fdTable) add(of *openFile) int32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fd := t.next
+	t.next++
+	t.files[fd] = of
+	t.dirWaiting[of.path] = append(t.dirWaiting[of.path], fd)
+	return fd
+}
+
+func (t *fdTable) get(fd int32) (*openFile, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	of, ok := t.files[fd]
+	return of, ok
+}
+
+func (t *fdTable) remove(fd int32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if of, ok := t.files[fd]; ok {
+		t.removeWaiter(of.path, fd)
+	}
+	delete(t.files, fd)
+}
+
+// removeWaiter drops fd from path's wait queue, e.g. when it's closed
+// before ever having its directory listing read.
+func (t *fdTable) removeWaiter(path string, fd int32) {
+	waiting := t.dirWaiting[path]
+	for i, w := range waiting {
+		if w == fd {
+			t.dirWaiting[path] = append(waiting[:i], waiting[i+1:]...)
+			break
+		}
+	}
+}
+
+// claimDirRead returns the openFile fsReaddir should serve for path. If an
+// fd opened against path is still waiting for its first listing, that
+// oldest waiter is popped off the queue and returned with fresh=true, so a
+// second, still-unread fd concurrently open on the same path claims its
+// own turn next rather than both racing over one shared flag. Once every
+// fd open against path has already been served, one of the served fds
+// (which one is not defined) is returned with fresh=false so a repeat call
+// gets an empty listing instead of the full one again.
+func (t *fdTable) claimDirRead(path string) (of *openFile, fresh, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if waiting := t.dirWaiting[path]; len(waiting) > 0 {
+		next := waiting[0]
+		t.dirWaiting[path] = waiting[1:]
+		if of, ok := t.files[next]; ok {
+			of.dirRead = true
+			return of, true, true
+		}
+	}
+
+	for _, of := range t.files {
+		if of.path == path && of.dirRead {
+			return of, false, true
+		}
+	}
+	return nil, false, false
+}
+
 // fsErrorResponse unwraps a PathError response.
 func fsErrorResponse(err error) []any {
 	if os.IsNotExist(err) {
@@ -77,36 +284,83 @@ func jsBoolFunc(b bool) *jsFunction {
 	return funcFalse
 }
 
+// StatInfo carries the portable metadata jsStat needs beyond what
+// fs.FileInfo exposes: ownership, device/inode identity, and the three
+// Unix timestamps. It plays the same role as restic's node.go does for
+// syscall.Stat_t - a platform-independent struct a HostFileInfo can fill in
+// from whatever the underlying OS actually provides.
+type StatInfo struct {
+	Uid, Gid            uint32
+	Dev, Ino            uint64
+	Nlink               uint64
+	Rdev                uint64
+	Blksize             int64
+	Blocks              int64
+	Atime, Mtime, Ctime time.Time
+}
+
+// HostFileInfo is an optional interface a HostFS's fs.FileInfo values may
+// implement to supply the metadata StatInfo describes. When Sys returns a
+// *StatInfo, jsStat uses it instead of falling back to its defaults.
+type HostFileInfo interface {
+	fs.FileInfo
+
+	// Sys returns additional metadata, ideally a *StatInfo.
+	Sys() any
+}
+
 // jsStat converts the FileInfo into the equivalent JS object.
 func jsStat(info fs.FileInfo) *jsObject {
 	if info == nil {
 		return nil
 	}
 	const blockSize = 4096 // TODO find useful value for blksize
-	modTime := info.ModTime().UnixNano() / 1e6
+
+	mode := info.Mode()
+
+	stat := &StatInfo{
+		Blksize: blockSize,
+		Blocks:  blockCount(info.Size(), blockSize),
+		Nlink:   1,
+	}
+	if hfi, ok := info.(HostFileInfo); ok {
+		if s, ok := hfi.Sys().(*StatInfo); ok && s != nil {
+			stat = s
+		}
+	}
+	if stat.Atime.IsZero() {
+		stat.Atime = info.ModTime()
+	}
+	if stat.Mtime.IsZero() {
+		stat.Mtime = info.ModTime()
+	}
+	if stat.Ctime.IsZero() {
+		stat.Ctime = info.ModTime()
+	}
+
 	return &jsObject{
 		properties: jsProperties{
-			"dev":     0,
-			"ino":     0,
-			"mode":    jsMode(info.Mode()),
-			"nlink":   1,
-			"uid":     0, // TODO use real values for uid and gid
-			"gid":     0,
-			"rdev":    0,
+			"dev":     stat.Dev,
+			"ino":     stat.Ino,
+			"mode":    jsMode(mode),
+			"nlink":   stat.Nlink,
+			"uid":     stat.Uid,
+			"gid":     stat.Gid,
+			"rdev":    stat.Rdev,
 			"size":    info.Size(),
-			"blksize": blockSize,
-			"blocks":  blockCount(info.Size(), blockSize),
-			"atimeMs": modTime,
-			"mtimeMs": modTime,
-			"ctimeMs": modTime,
-
-			"isBlockDevice":     funcFalse,
-			"isCharacterDevice": funcFalse,
+			"blksize": stat.Blksize,
+			"blocks":  stat.Blocks,
+			"atimeMs": stat.Atime.UnixNano() / 1e6,
+			"mtimeMs": stat.Mtime.UnixNano() / 1e6,
+			"ctimeMs": stat.Ctime.UnixNano() / 1e6,
+
+			"isBlockDevice":     jsBoolFunc(mode&fs.ModeDevice != 0 && mode&fs.ModeCharDevice == 0),
+			"isCharacterDevice": jsBoolFunc(mode&fs.ModeCharDevice != 0),
 			"isDirectory":       jsBoolFunc(info.IsDir()),
-			"isFIFO":            funcFalse,
-			"isFile":            jsBoolFunc(info.Mode().IsRegular()),
-			"isSocket":          funcFalse,
-			"isSymbolicLink":    jsBoolFunc(info.Mode()&fs.ModeSymlink == fs.ModeSymlink),
+			"isFIFO":            jsBoolFunc(mode&fs.ModeNamedPipe != 0),
+			"isFile":            jsBoolFunc(mode.IsRegular()),
+			"isSocket":          jsBoolFunc(mode&fs.ModeSocket != 0),
+			"isSymbolicLink":    jsBoolFunc(mode&fs.ModeSymlink != 0),
 		},
 	}
 }
@@ -190,3 +444,1098 @@ func fsStat(mod *Module, hostFS HostFS) *jsFunction {
 		},
 	}
 }
+
+// fsOpen implements the open syscall callback
+// open(path, flags, mode, callback)
+func fsOpen(mod *Module, hostFS HostFS, fds *fdTable) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 4 {
+				mod.error("fs.open: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fpath, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.open: %T: not type string", args[0])
+				return nil
+			}
+
+			flags, ok := args[1].(int)
+			if !ok {
+				mod.error("fs.open: %T: not type int", args[1])
+				return nil
+			}
+
+			perm, ok := args[2].(int)
+			if !ok {
+				mod.error("fs.open: %T: not type int", args[2])
+				return nil
+			}
+
+			callback, ok := args[3].(*jsFunction)
+			if !ok {
+				mod.error("fs.open: %T: not type jsFunction", args[3])
+				return nil
+			}
+
+			file, err := openHostFile(hostFS, fpath.data, flags, fs.FileMode(perm))
+			if err != nil {
+				mod.error("fs.open: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			fd := fds.add(&openFile{path: fpath.data, file: file})
+			callback.fn([]any{nil, int(fd)})
+			return nil
+		},
+	}
+}
+
+// openHostFile opens fpath against hostFS, using the HostWriteFS.OpenFile
+// method when flags require write access and falling back to the read-only
+// fs.FS.Open otherwise.
+func openHostFile(hostFS HostFS, fpath string, flags int, perm fs.FileMode) (File, error) {
+	const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_CREATE | os.O_TRUNC | os.O_APPEND | os.O_EXCL
+
+	if flags&writeFlags != 0 {
+		writeFS, ok := hostFS.(HostWriteFS)
+		if !ok {
+			return nil, syscall.ENOSYS
+		}
+		return writeFS.OpenFile(fpath, flags, perm)
+	}
+
+	f, err := hostFS.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	if file, ok := f.(File); ok {
+		return file, nil
+	}
+	return readOnlyFile{f}, nil
+}
+
+// fsClose implements the close syscall callback
+// close(fd, callback)
+func fsClose(mod *Module, fds *fdTable) *jsFunction {
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 2 {
+				mod.error("fs.close: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fd, ok := args[0].(int)
+			if !ok {
+				mod.error("fs.close: %T: not type int", args[0])
+				return nil
+			}
+
+			callback, ok := args[1].(*jsFunction)
+			if !ok {
+				mod.error("fs.close: %T: not type jsFunction", args[1])
+				return nil
+			}
+
+			of, ok := fds.get(int32(fd))
+			if !ok {
+				return fsErrorResponse(syscall.EBADF)
+			}
+			fds.remove(int32(fd))
+
+			if err := of.file.Close(); err != nil {
+				mod.error("fs.close: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
+
+// fsRead implements the read syscall callback
+// read(fd, buffer, offset, length, position, callback)
+func fsRead(mod *Module, fds *fdTable) *jsFunction {
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 6 {
+				mod.error("fs.read: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fd, ok := args[0].(int)
+			if !ok {
+				mod.error("fs.read: %T: not type int", args[0])
+				return nil
+			}
+
+			buf, ok := args[1].([]byte)
+			if !ok {
+				mod.error("fs.read: %T: not type []byte", args[1])
+				return nil
+			}
+
+			offset, ok := args[2].(int)
+			if !ok {
+				mod.error("fs.read: %T: not type int", args[2])
+				return nil
+			}
+
+			length, ok := args[3].(int)
+			if !ok {
+				mod.error("fs.read: %T: not type int", args[3])
+				return nil
+			}
+
+			callback, ok := args[5].(*jsFunction)
+			if !ok {
+				mod.error("fs.read: %T: not type jsFunction", args[5])
+				return nil
+			}
+
+			of, ok := fds.get(int32(fd))
+			if !ok {
+				return fsErrorResponse(syscall.EBADF)
+			}
+
+			if position, ok := args[4].(int64); ok && position >= 0 {
+				if err := seekTo(of, position); err != nil {
+					mod.error("fs.read: %v", err)
+					return fsErrorResponse(err)
+				}
+			}
+
+			slice, err := sliceBuf(buf, offset, length)
+			if err != nil {
+				mod.error("fs.read: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			n, err := of.file.Read(slice)
+			of.offset += int64(n)
+			if err != nil && err != io.EOF {
+				mod.error("fs.read: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil, n})
+			return nil
+		},
+	}
+}
+
+// fsWrite implements the write syscall callback
+// write(fd, buffer, offset, length, position, callback)
+func fsWrite(mod *Module, fds *fdTable) *jsFunction {
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 6 {
+				mod.error("fs.write: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fd, ok := args[0].(int)
+			if !ok {
+				mod.error("fs.write: %T: not type int", args[0])
+				return nil
+			}
+
+			buf, ok := args[1].([]byte)
+			if !ok {
+				mod.error("fs.write: %T: not type []byte", args[1])
+				return nil
+			}
+
+			offset, ok := args[2].(int)
+			if !ok {
+				mod.error("fs.write: %T: not type int", args[2])
+				return nil
+			}
+
+			length, ok := args[3].(int)
+			if !ok {
+				mod.error("fs.write: %T: not type int", args[3])
+				return nil
+			}
+
+			callback, ok := args[5].(*jsFunction)
+			if !ok {
+				mod.error("fs.write: %T: not type jsFunction", args[5])
+				return nil
+			}
+
+			of, ok := fds.get(int32(fd))
+			if !ok {
+				return fsErrorResponse(syscall.EBADF)
+			}
+
+			if position, ok := args[4].(int64); ok && position >= 0 {
+				if err := seekTo(of, position); err != nil {
+					mod.error("fs.write: %v", err)
+					return fsErrorResponse(err)
+				}
+			}
+
+			slice, err := sliceBuf(buf, offset, length)
+			if err != nil {
+				mod.error("fs.write: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			n, err := of.file.Write(slice)
+			of.offset += int64(n)
+			if err != nil {
+				mod.error("fs.write: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil, n})
+			return nil
+		},
+	}
+}
+
+// sliceBuf validates offset/length against buf before slicing it, so a
+// guest passing a bogus pair gets EINVAL instead of panicking the host with
+// an index-out-of-range.
+func sliceBuf(buf []byte, offset, length int) ([]byte, error) {
+	if offset < 0 || length < 0 || offset+length > len(buf) {
+		return nil, syscall.EINVAL
+	}
+	return buf[offset : offset+length], nil
+}
+
+// seekTo seeks of's underlying file to position, keeping of.offset in sync.
+func seekTo(of *openFile, position int64) error {
+	if _, err := of.file.Seek(position, io.SeekStart); err != nil {
+		return err
+	}
+	of.offset = position
+	return nil
+}
+
+// fsPread implements the pread syscall callback: a read at an explicit
+// position that leaves the file descriptor's current offset untouched.
+// pread(fd, buffer, offset, length, position, callback)
+func fsPread(mod *Module, fds *fdTable) *jsFunction {
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 6 {
+				mod.error("fs.pread: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fd, ok := args[0].(int)
+			if !ok {
+				mod.error("fs.pread: %T: not type int", args[0])
+				return nil
+			}
+
+			buf, ok := args[1].([]byte)
+			if !ok {
+				mod.error("fs.pread: %T: not type []byte", args[1])
+				return nil
+			}
+
+			offset, ok := args[2].(int)
+			if !ok {
+				mod.error("fs.pread: %T: not type int", args[2])
+				return nil
+			}
+
+			length, ok := args[3].(int)
+			if !ok {
+				mod.error("fs.pread: %T: not type int", args[3])
+				return nil
+			}
+
+			position, ok := args[4].(int64)
+			if !ok {
+				mod.error("fs.pread: %T: not type int64", args[4])
+				return nil
+			}
+
+			callback, ok := args[5].(*jsFunction)
+			if !ok {
+				mod.error("fs.pread: %T: not type jsFunction", args[5])
+				return nil
+			}
+
+			of, ok := fds.get(int32(fd))
+			if !ok {
+				return fsErrorResponse(syscall.EBADF)
+			}
+
+			saved := of.offset
+			defer seekTo(of, saved)
+
+			if err := seekTo(of, position); err != nil {
+				mod.error("fs.pread: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			slice, err := sliceBuf(buf, offset, length)
+			if err != nil {
+				mod.error("fs.pread: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			n, err := of.file.Read(slice)
+			if err != nil && err != io.EOF {
+				mod.error("fs.pread: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil, n})
+			return nil
+		},
+	}
+}
+
+// fsPwrite implements the pwrite syscall callback: a write at an explicit
+// position that leaves the file descriptor's current offset untouched.
+// pwrite(fd, buffer, offset, length, position, callback)
+func fsPwrite(mod *Module, fds *fdTable) *jsFunction {
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 6 {
+				mod.error("fs.pwrite: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fd, ok := args[0].(int)
+			if !ok {
+				mod.error("fs.pwrite: %T: not type int", args[0])
+				return nil
+			}
+
+			buf, ok := args[1].([]byte)
+			if !ok {
+				mod.error("fs.pwrite: %T: not type []byte", args[1])
+				return nil
+			}
+
+			offset, ok := args[2].(int)
+			if !ok {
+				mod.error("fs.pwrite: %T: not type int", args[2])
+				return nil
+			}
+
+			length, ok := args[3].(int)
+			if !ok {
+				mod.error("fs.pwrite: %T: not type int", args[3])
+				return nil
+			}
+
+			position, ok := args[4].(int64)
+			if !ok {
+				mod.error("fs.pwrite: %T: not type int64", args[4])
+				return nil
+			}
+
+			callback, ok := args[5].(*jsFunction)
+			if !ok {
+				mod.error("fs.pwrite: %T: not type jsFunction", args[5])
+				return nil
+			}
+
+			of, ok := fds.get(int32(fd))
+			if !ok {
+				return fsErrorResponse(syscall.EBADF)
+			}
+
+			saved := of.offset
+			defer seekTo(of, saved)
+
+			if err := seekTo(of, position); err != nil {
+				mod.error("fs.pwrite: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			slice, err := sliceBuf(buf, offset, length)
+			if err != nil {
+				mod.error("fs.pwrite: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			n, err := of.file.Write(slice)
+			if err != nil {
+				mod.error("fs.pwrite: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil, n})
+			return nil
+		},
+	}
+}
+
+// fsFsync implements the fsync syscall callback
+// fsync(fd, callback)
+func fsFsync(mod *Module, fds *fdTable) *jsFunction {
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 2 {
+				mod.error("fs.fsync: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fd, ok := args[0].(int)
+			if !ok {
+				mod.error("fs.fsync: %T: not type int", args[0])
+				return nil
+			}
+
+			callback, ok := args[1].(*jsFunction)
+			if !ok {
+				mod.error("fs.fsync: %T: not type jsFunction", args[1])
+				return nil
+			}
+
+			of, ok := fds.get(int32(fd))
+			if !ok {
+				return fsErrorResponse(syscall.EBADF)
+			}
+
+			if err := of.file.Sync(); err != nil {
+				mod.error("fs.fsync: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
+
+// fsMkdir implements the mkdir syscall callback
+// mkdir(path, mode, callback)
+func fsMkdir(mod *Module, hostFS HostWriteFS) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 3 {
+				mod.error("fs.mkdir: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fpath, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.mkdir: %T: not type string", args[0])
+				return nil
+			}
+
+			mode, ok := args[1].(int)
+			if !ok {
+				mod.error("fs.mkdir: %T: not type int", args[1])
+				return nil
+			}
+
+			callback, ok := args[2].(*jsFunction)
+			if !ok {
+				mod.error("fs.mkdir: %T: not type jsFunction", args[2])
+				return nil
+			}
+
+			if err := hostFS.Mkdir(fpath.data, fs.FileMode(mode)); err != nil {
+				mod.error("fs.mkdir: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
+
+// fsUnlink implements the unlink syscall callback
+// unlink(path, callback)
+func fsUnlink(mod *Module, hostFS HostWriteFS) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 2 {
+				mod.error("fs.unlink: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fpath, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.unlink: %T: not type string", args[0])
+				return nil
+			}
+
+			callback, ok := args[1].(*jsFunction)
+			if !ok {
+				mod.error("fs.unlink: %T: not type jsFunction", args[1])
+				return nil
+			}
+
+			info, err := hostFS.Stat(fpath.data)
+			if err == nil && info.IsDir() {
+				return fsErrorResponse(syscall.EISDIR)
+			}
+
+			if err := hostFS.Remove(fpath.data); err != nil {
+				mod.error("fs.unlink: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
+
+// fsRmdir implements the rmdir syscall callback
+// rmdir(path, callback)
+func fsRmdir(mod *Module, hostFS HostWriteFS) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 2 {
+				mod.error("fs.rmdir: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fpath, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.rmdir: %T: not type string", args[0])
+				return nil
+			}
+
+			callback, ok := args[1].(*jsFunction)
+			if !ok {
+				mod.error("fs.rmdir: %T: not type jsFunction", args[1])
+				return nil
+			}
+
+			info, err := hostFS.Stat(fpath.data)
+			if err == nil && !info.IsDir() {
+				return fsErrorResponse(syscall.ENOTDIR)
+			}
+
+			if err := hostFS.Remove(fpath.data); err != nil {
+				mod.error("fs.rmdir: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
+
+// fsRename implements the rename syscall callback
+// rename(from, to, callback)
+func fsRename(mod *Module, hostFS HostWriteFS) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 3 {
+				mod.error("fs.rename: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			from, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.rename: %T: not type string", args[0])
+				return nil
+			}
+
+			to, ok := args[1].(*jsString)
+			if !ok {
+				mod.error("fs.rename: %T: not type string", args[1])
+				return nil
+			}
+
+			callback, ok := args[2].(*jsFunction)
+			if !ok {
+				mod.error("fs.rename: %T: not type jsFunction", args[2])
+				return nil
+			}
+
+			if err := hostFS.Rename(from.data, to.data); err != nil {
+				mod.error("fs.rename: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
+
+// fsTruncate implements the truncate syscall callback
+// truncate(path, length, callback)
+func fsTruncate(mod *Module, hostFS HostWriteFS) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 3 {
+				mod.error("fs.truncate: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fpath, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.truncate: %T: not type string", args[0])
+				return nil
+			}
+
+			length, ok := args[1].(int64)
+			if !ok {
+				mod.error("fs.truncate: %T: not type int64", args[1])
+				return nil
+			}
+
+			callback, ok := args[2].(*jsFunction)
+			if !ok {
+				mod.error("fs.truncate: %T: not type jsFunction", args[2])
+				return nil
+			}
+
+			if err := hostFS.Truncate(fpath.data, length); err != nil {
+				mod.error("fs.truncate: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
+
+// fsFtruncate implements the ftruncate syscall callback
+// ftruncate(fd, length, callback)
+func fsFtruncate(mod *Module, fds *fdTable) *jsFunction {
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 3 {
+				mod.error("fs.ftruncate: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fd, ok := args[0].(int)
+			if !ok {
+				mod.error("fs.ftruncate: %T: not type int", args[0])
+				return nil
+			}
+
+			length, ok := args[1].(int64)
+			if !ok {
+				mod.error("fs.ftruncate: %T: not type int64", args[1])
+				return nil
+			}
+
+			callback, ok := args[2].(*jsFunction)
+			if !ok {
+				mod.error("fs.ftruncate: %T: not type jsFunction", args[2])
+				return nil
+			}
+
+			of, ok := fds.get(int32(fd))
+			if !ok {
+				return fsErrorResponse(syscall.EBADF)
+			}
+
+			if err := of.file.Truncate(length); err != nil {
+				mod.error("fs.ftruncate: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
+
+// fsUtimes implements the utimes syscall callback
+// utimes(path, atime, mtime, callback)
+func fsUtimes(mod *Module, hostFS HostWriteFS) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 4 {
+				mod.error("fs.utimes: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fpath, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.utimes: %T: not type string", args[0])
+				return nil
+			}
+
+			atime, ok := args[1].(int64)
+			if !ok {
+				mod.error("fs.utimes: %T: not type int64", args[1])
+				return nil
+			}
+
+			mtime, ok := args[2].(int64)
+			if !ok {
+				mod.error("fs.utimes: %T: not type int64", args[2])
+				return nil
+			}
+
+			callback, ok := args[3].(*jsFunction)
+			if !ok {
+				mod.error("fs.utimes: %T: not type jsFunction", args[3])
+				return nil
+			}
+
+			err := hostFS.Chtimes(fpath.data, time.Unix(atime, 0), time.Unix(mtime, 0))
+			if err != nil {
+				mod.error("fs.utimes: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
+
+// fsFchmod implements the fchmod syscall callback
+// fchmod(fd, mode, callback)
+func fsFchmod(mod *Module, hostFS HostFS, fds *fdTable) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 3 {
+				mod.error("fs.fchmod: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fd, ok := args[0].(int)
+			if !ok {
+				mod.error("fs.fchmod: %T: not type int", args[0])
+				return nil
+			}
+
+			mode, ok := args[1].(int)
+			if !ok {
+				mod.error("fs.fchmod: %T: not type int", args[1])
+				return nil
+			}
+
+			callback, ok := args[2].(*jsFunction)
+			if !ok {
+				mod.error("fs.fchmod: %T: not type jsFunction", args[2])
+				return nil
+			}
+
+			of, ok := fds.get(int32(fd))
+			if !ok {
+				return fsErrorResponse(syscall.EBADF)
+			}
+
+			if err := hostFS.Chmod(of.path, fs.FileMode(mode)); err != nil {
+				mod.error("fs.fchmod: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
+
+// fsFchown implements the fchown syscall callback
+// fchown(fd, uid, gid, callback)
+//
+// HostWriteFS has no notion of file ownership, so this always reports
+// ENOSYS; it exists so the Go runtime's os.Chown gets a clean error instead
+// of an undefined import.
+func fsFchown(mod *Module) *jsFunction {
+	return errorCallback(eNOSYS)
+}
+
+// fsLchown implements the lchown syscall callback
+// lchown(path, uid, gid, callback)
+//
+// See fsFchown: ownership isn't modeled by HostWriteFS, so this always
+// reports ENOSYS.
+func fsLchown(mod *Module) *jsFunction {
+	return errorCallback(eNOSYS)
+}
+
+// fsFstat implements the fstat syscall callback
+// fstat(fd, callback)
+func fsFstat(mod *Module, fds *fdTable) *jsFunction {
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 2 {
+				mod.error("fs.fstat: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fd, ok := args[0].(int)
+			if !ok {
+				mod.error("fs.fstat: %T: not type int", args[0])
+				return nil
+			}
+
+			callback, ok := args[1].(*jsFunction)
+			if !ok {
+				mod.error("fs.fstat: %T: not type jsFunction", args[1])
+				return nil
+			}
+
+			of, ok := fds.get(int32(fd))
+			if !ok {
+				return fsErrorResponse(syscall.EBADF)
+			}
+
+			fi, err := of.file.Stat()
+			if err != nil {
+				mod.error("fs.fstat: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{jsStat(fi)})
+			return nil
+		},
+	}
+}
+
+// fsReaddir implements the readdir syscall callback
+// readdir(path, callback)
+//
+// The Go runtime opens a directory through the same fd as any other file
+// (see fsOpen/fsFstat) but reads its listing through this separate
+// path-based callback, and the common Readdirnames(n)-in-a-loop idiom
+// calls it again once its cached batch is exhausted. Since there's no
+// offset to page through here, a repeated call for the same open dir fd
+// returns an empty listing rather than the full one again, so callers
+// that loop on it terminate instead of looping on duplicate entries
+// forever. claimDirRead pairs each call with a specific, not-yet-served
+// fd (see its doc comment) so two fds concurrently open on the same path
+// each get their own listing rather than racing over a shared flag. A
+// path with no open fd at all (e.g. the host wasn't asked through fsOpen)
+// always gets a fresh listing.
+func fsReaddir(mod *Module, hostFS HostFS, fds *fdTable) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 2 {
+				mod.error("fs.readdir: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fpath, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.readdir: %T: not type string", args[0])
+				return nil
+			}
+
+			callback, ok := args[1].(*jsFunction)
+			if !ok {
+				mod.error("fs.readdir: %T: not type jsFunction", args[1])
+				return nil
+			}
+
+			if _, fresh, found := fds.claimDirRead(fpath.data); found && !fresh {
+				callback.fn([]any{nil, &jsArray{}})
+				return nil
+			}
+
+			entries, err := hostFS.ReadDir(fpath.data)
+			if err != nil {
+				mod.error("fs.readdir: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			names := make([]any, len(entries))
+			for i, entry := range entries {
+				names[i] = &jsString{data: entry.Name()}
+			}
+
+			callback.fn([]any{nil, &jsArray{values: names}})
+			return nil
+		},
+	}
+}
+
+// fsLstat implements the lstat syscall callback
+// lstat(path, callback)
+//
+// Unlike fsStat, this must not follow a symlink: jsStat already reports
+// fs.ModeSymlink correctly as long as the FileInfo it's given describes the
+// link itself, which is what HostSymlinkFS.Lstat guarantees.
+func fsLstat(mod *Module, hostFS HostSymlinkFS) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 2 {
+				mod.error("fs.lstat: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fpath, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.lstat: %T: not type string", args[0])
+				return nil
+			}
+
+			callback, ok := args[1].(*jsFunction)
+			if !ok {
+				mod.error("fs.lstat: %T: not type jsFunction", args[1])
+				return nil
+			}
+
+			fi, err := hostFS.Lstat(fpath.data)
+			if err != nil {
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{jsStat(fi)})
+			return nil
+		},
+	}
+}
+
+// fsSymlink implements the symlink syscall callback
+// symlink(oldpath, newpath, callback)
+func fsSymlink(mod *Module, hostFS HostSymlinkFS) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 3 {
+				mod.error("fs.symlink: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			oldpath, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.symlink: %T: not type string", args[0])
+				return nil
+			}
+
+			newpath, ok := args[1].(*jsString)
+			if !ok {
+				mod.error("fs.symlink: %T: not type string", args[1])
+				return nil
+			}
+
+			callback, ok := args[2].(*jsFunction)
+			if !ok {
+				mod.error("fs.symlink: %T: not type jsFunction", args[2])
+				return nil
+			}
+
+			if err := hostFS.Symlink(oldpath.data, newpath.data); err != nil {
+				mod.error("fs.symlink: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
+
+// fsReadlink implements the readlink syscall callback
+// readlink(path, callback)
+func fsReadlink(mod *Module, hostFS HostSymlinkFS) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 2 {
+				mod.error("fs.readlink: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			fpath, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.readlink: %T: not type string", args[0])
+				return nil
+			}
+
+			callback, ok := args[1].(*jsFunction)
+			if !ok {
+				mod.error("fs.readlink: %T: not type jsFunction", args[1])
+				return nil
+			}
+
+			dest, err := hostFS.Readlink(fpath.data)
+			if err != nil {
+				mod.error("fs.readlink: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil, &jsString{data: dest}})
+			return nil
+		},
+	}
+}
+
+// fsLink implements the link syscall callback
+// link(oldpath, newpath, callback)
+func fsLink(mod *Module, hostFS HostSymlinkFS) *jsFunction {
+	if hostFS == nil {
+		return errorCallback(eNOSYS)
+	}
+	return &jsFunction{
+		fn: func(args []any) any {
+			if len(args) != 3 {
+				mod.error("fs.link: %d: invalid number of arguments", len(args))
+				return nil
+			}
+
+			oldpath, ok := args[0].(*jsString)
+			if !ok {
+				mod.error("fs.link: %T: not type string", args[0])
+				return nil
+			}
+
+			newpath, ok := args[1].(*jsString)
+			if !ok {
+				mod.error("fs.link: %T: not type string", args[1])
+				return nil
+			}
+
+			callback, ok := args[2].(*jsFunction)
+			if !ok {
+				mod.error("fs.link: %T: not type jsFunction", args[2])
+				return nil
+			}
+
+			if err := hostFS.Link(oldpath.data, newpath.data); err != nil {
+				mod.error("fs.link: %v", err)
+				return fsErrorResponse(err)
+			}
+
+			callback.fn([]any{nil})
+			return nil
+		},
+	}
+}
@@ -0,0 +1,214 @@
+// Package hostfs provides reference wasmexec.HostFS implementations so
+// guest programs have a batteries-included filesystem without every caller
+// re-implementing the open/read/write/readdir plumbing wasmexec.HostFS,
+// wasmexec.HostWriteFS, and wasmexec.HostSymlinkFS require.
+package hostfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/prep/wasmexec"
+)
+
+// dirFS is a HostFS backed directly by a directory on the real filesystem,
+// with paths resolved the same way os.DirFS resolves them.
+type dirFS struct {
+	root string
+}
+
+// New returns a HostFS rooted at root, implementing the full
+// HostFS/HostWriteFS/HostSymlinkFS surface on top of the os package.
+func New(root string) wasmexec.HostFS {
+	return &dirFS{root: root}
+}
+
+// join resolves name (a slash-separated path as used by io/fs) against the
+// root directory, rejecting paths that escape it.
+func (d *dirFS) join(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.root, filepath.FromSlash(name)), nil
+}
+
+func (d *dirFS) Open(name string) (fs.File, error) {
+	path, err := d.join(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return hostFile{file}, nil
+}
+
+func (d *dirFS) Stat(name string) (fs.FileInfo, error) {
+	path, err := d.join(name)
+	if err != nil {
+		return nil, err
+	}
+	return wrapStat(os.Stat(path))
+}
+
+func (d *dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	path, err := d.join(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(path)
+}
+
+func (d *dirFS) Chmod(name string, mode fs.FileMode) error {
+	path, err := d.join(name)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
+
+func (d *dirFS) OpenFile(name string, flag int, perm fs.FileMode) (wasmexec.File, error) {
+	path, err := d.join(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return hostFile{file}, nil
+}
+
+func (d *dirFS) Mkdir(name string, perm fs.FileMode) error {
+	path, err := d.join(name)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(path, perm)
+}
+
+func (d *dirFS) Remove(name string) error {
+	path, err := d.join(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (d *dirFS) Rename(oldname, newname string) error {
+	oldpath, err := d.join(oldname)
+	if err != nil {
+		return err
+	}
+	newpath, err := d.join(newname)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+func (d *dirFS) Truncate(name string, size int64) error {
+	path, err := d.join(name)
+	if err != nil {
+		return err
+	}
+	return os.Truncate(path, size)
+}
+
+func (d *dirFS) Chtimes(name string, atime, mtime time.Time) error {
+	path, err := d.join(name)
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(path, atime, mtime)
+}
+
+func (d *dirFS) Lstat(name string) (fs.FileInfo, error) {
+	path, err := d.join(name)
+	if err != nil {
+		return nil, err
+	}
+	return wrapStat(os.Lstat(path))
+}
+
+func (d *dirFS) Symlink(oldname, newname string) error {
+	newpath, err := d.join(newname)
+	if err != nil {
+		return err
+	}
+	// oldname is the link's target and is stored verbatim, so it is not
+	// resolved against root the way newname is.
+	return os.Symlink(oldname, newpath)
+}
+
+func (d *dirFS) Readlink(name string) (string, error) {
+	path, err := d.join(name)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(path)
+}
+
+func (d *dirFS) Link(oldname, newname string) error {
+	oldpath, err := d.join(oldname)
+	if err != nil {
+		return err
+	}
+	newpath, err := d.join(newname)
+	if err != nil {
+		return err
+	}
+	return os.Link(oldpath, newpath)
+}
+
+// hostFile wraps an *os.File opened through dirFS so its Stat method
+// reports a *wasmexec.StatInfo via statInfo, the same as dirFS's own
+// Stat/Lstat, so jsStat sees real metadata for an fstat on an open fd too.
+type hostFile struct {
+	*os.File
+}
+
+func (f hostFile) Stat() (fs.FileInfo, error) {
+	return wrapStat(f.File.Stat())
+}
+
+// statInfo wraps an fs.FileInfo backed by a real file on this OS so its Sys
+// method reports a *wasmexec.StatInfo instead of the OS-specific
+// *syscall.Stat_t, satisfying wasmexec.HostFileInfo so jsStat can report
+// real uid/gid/ino/timestamps instead of falling back to its defaults.
+type statInfo struct {
+	fs.FileInfo
+}
+
+func (s statInfo) Sys() any {
+	st, ok := s.FileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return &wasmexec.StatInfo{
+		Uid:     st.Uid,
+		Gid:     st.Gid,
+		Dev:     uint64(st.Dev),
+		Ino:     st.Ino,
+		Nlink:   uint64(st.Nlink),
+		Rdev:    uint64(st.Rdev),
+		Blksize: int64(st.Blksize),
+		Blocks:  int64(st.Blocks),
+		Atime:   time.Unix(st.Atim.Sec, st.Atim.Nsec),
+		Mtime:   time.Unix(st.Mtim.Sec, st.Mtim.Nsec),
+		Ctime:   time.Unix(st.Ctim.Sec, st.Ctim.Nsec),
+	}
+}
+
+// wrapStat adapts info so its Sys method satisfies wasmexec.HostFileInfo,
+// passing an error through unchanged.
+func wrapStat(info fs.FileInfo, err error) (fs.FileInfo, error) {
+	if err != nil {
+		return nil, err
+	}
+	return statInfo{info}, nil
+}
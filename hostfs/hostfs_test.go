@@ -0,0 +1,352 @@
+package hostfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prep/wasmexec"
+)
+
+func TestDirFSReadWrite(t *testing.T) {
+	root := t.TempDir()
+	h := New(root).(wasmexec.HostWriteFS)
+
+	f, err := h.OpenFile("greeting.txt", os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := f.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got, err := fs.ReadFile(h, "greeting.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("content = %q, want %q", got, "hello")
+	}
+}
+
+func TestDirFSRemoveMissing(t *testing.T) {
+	h := New(t.TempDir()).(wasmexec.HostWriteFS)
+
+	if err := h.Remove("nope.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Remove(missing) = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestOverlayStatMissingIsNotExist(t *testing.T) {
+	base := os.DirFS(t.TempDir())
+	o := NewOverlay(base, t.TempDir())
+
+	if _, err := o.Stat("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat(missing) = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestOverlayCopyOnWrite(t *testing.T) {
+	baseRoot := t.TempDir()
+	if err := os.WriteFile(baseRoot+"/file.txt", []byte("base"), 0o644); err != nil {
+		t.Fatalf("seed base file: %v", err)
+	}
+
+	o := NewOverlay(os.DirFS(baseRoot), t.TempDir()).(wasmexec.HostWriteFS)
+
+	f, err := o.OpenFile("file.txt", os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := f.Write([]byte("overlay")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// base on disk must be untouched; the write landed in the scratch dir.
+	got, err := os.ReadFile(baseRoot + "/file.txt")
+	if err != nil {
+		t.Fatalf("ReadFile(base): %v", err)
+	}
+	if string(got) != "base" {
+		t.Fatalf("base file mutated: got %q", got)
+	}
+
+	overlaid, err := fs.ReadFile(o, "file.txt")
+	if err != nil {
+		t.Fatalf("ReadFile(overlay): %v", err)
+	}
+	if string(overlaid) != "overlay" {
+		t.Fatalf("overlay content = %q, want %q", overlaid, "overlay")
+	}
+}
+
+func TestOverlayRemoveMissingIsNotExist(t *testing.T) {
+	o := NewOverlay(os.DirFS(t.TempDir()), t.TempDir()).(wasmexec.HostWriteFS)
+
+	if err := o.Remove("totally/bogus/path"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Remove(bogus) = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestOverlayRemoveFromBase(t *testing.T) {
+	baseRoot := t.TempDir()
+	if err := os.WriteFile(baseRoot+"/file.txt", []byte("base"), 0o644); err != nil {
+		t.Fatalf("seed base file: %v", err)
+	}
+
+	o := NewOverlay(os.DirFS(baseRoot), t.TempDir()).(wasmexec.HostWriteFS)
+
+	if err := o.Remove("file.txt"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := o.Stat("file.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat(removed) = %v, want fs.ErrNotExist", err)
+	}
+
+	// The tombstone must not turn a second, already-gone Remove into a
+	// false success either.
+	if err := o.Remove("file.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("second Remove = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestDirFSMkdirReadDir(t *testing.T) {
+	h := New(t.TempDir()).(wasmexec.HostWriteFS)
+
+	if err := h.Mkdir("sub", 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if _, err := h.OpenFile("sub/file.txt", os.O_WRONLY|os.O_CREATE, 0o644); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+
+	entries, err := h.ReadDir("sub")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "file.txt" {
+		t.Fatalf("ReadDir(sub) = %v, want [file.txt]", entries)
+	}
+}
+
+func TestDirFSRename(t *testing.T) {
+	root := t.TempDir()
+	h := New(root).(wasmexec.HostWriteFS)
+
+	if err := os.WriteFile(root+"/old.txt", []byte("data"), 0o644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	if err := h.Rename("old.txt", "new.txt"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, err := h.Stat("old.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat(old) = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := h.Stat("new.txt"); err != nil {
+		t.Fatalf("Stat(new): %v", err)
+	}
+}
+
+func TestDirFSSymlinkReadlink(t *testing.T) {
+	root := t.TempDir()
+	h := New(root).(wasmexec.HostSymlinkFS)
+
+	if err := os.WriteFile(root+"/target.txt", []byte("data"), 0o644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	if err := h.Symlink("target.txt", "link.txt"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	got, err := h.Readlink("link.txt")
+	if err != nil {
+		t.Fatalf("Readlink: %v", err)
+	}
+	if got != "target.txt" {
+		t.Fatalf("Readlink = %q, want %q", got, "target.txt")
+	}
+
+	info, err := h.Lstat("link.txt")
+	if err != nil {
+		t.Fatalf("Lstat: %v", err)
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("Lstat(link).Mode() = %v, want ModeSymlink set", info.Mode())
+	}
+}
+
+func TestDirFSLink(t *testing.T) {
+	root := t.TempDir()
+	h := New(root).(wasmexec.HostSymlinkFS)
+
+	if err := os.WriteFile(root+"/original.txt", []byte("data"), 0o644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	if err := h.Link("original.txt", "alias.txt"); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+
+	got, err := fs.ReadFile(h.(wasmexec.HostFS), "alias.txt")
+	if err != nil {
+		t.Fatalf("ReadFile(alias): %v", err)
+	}
+	if string(got) != "data" {
+		t.Fatalf("alias content = %q, want %q", got, "data")
+	}
+}
+
+func TestDirFSTruncateChtimes(t *testing.T) {
+	root := t.TempDir()
+	h := New(root).(wasmexec.HostWriteFS)
+
+	if err := os.WriteFile(root+"/file.txt", []byte("hello world"), 0o644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	if err := h.Truncate("file.txt", 5); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+	got, err := fs.ReadFile(h, "file.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("content = %q, want %q", got, "hello")
+	}
+
+	mtime := time.Unix(1000000, 0)
+	if err := h.Chtimes("file.txt", mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	info, err := h.Stat("file.txt")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Fatalf("ModTime = %v, want %v", info.ModTime(), mtime)
+	}
+}
+
+func TestOverlayMkdirRenameReadDir(t *testing.T) {
+	baseRoot := t.TempDir()
+	if err := os.Mkdir(baseRoot+"/basedir", 0o755); err != nil {
+		t.Fatalf("seed base dir: %v", err)
+	}
+	if err := os.WriteFile(baseRoot+"/basedir/file.txt", []byte("base"), 0o644); err != nil {
+		t.Fatalf("seed base file: %v", err)
+	}
+
+	o := NewOverlay(os.DirFS(baseRoot), t.TempDir()).(wasmexec.HostWriteFS)
+
+	if err := o.Mkdir("newdir", 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := o.Rename("basedir/file.txt", "newdir/file.txt"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	entries, err := o.ReadDir("newdir")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "file.txt" {
+		t.Fatalf("ReadDir(newdir) = %v, want [file.txt]", entries)
+	}
+
+	baseEntries, err := o.ReadDir("basedir")
+	if err != nil {
+		t.Fatalf("ReadDir(basedir): %v", err)
+	}
+	if len(baseEntries) != 0 {
+		t.Fatalf("ReadDir(basedir) = %v, want empty after rename away", baseEntries)
+	}
+}
+
+func TestOverlayRemoveNonEmptyDirFromBase(t *testing.T) {
+	baseRoot := t.TempDir()
+	if err := os.Mkdir(baseRoot+"/dir", 0o755); err != nil {
+		t.Fatalf("seed base dir: %v", err)
+	}
+	if err := os.WriteFile(baseRoot+"/dir/file.txt", []byte("base"), 0o644); err != nil {
+		t.Fatalf("seed base file: %v", err)
+	}
+
+	o := NewOverlay(os.DirFS(baseRoot), t.TempDir()).(wasmexec.HostWriteFS)
+
+	err := o.Remove("dir")
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Err != syscall.ENOTEMPTY {
+		t.Fatalf("Remove(non-empty dir) = %v, want ENOTEMPTY", err)
+	}
+}
+
+func TestOverlaySymlinkLink(t *testing.T) {
+	baseRoot := t.TempDir()
+	if err := os.WriteFile(baseRoot+"/target.txt", []byte("data"), 0o644); err != nil {
+		t.Fatalf("seed base file: %v", err)
+	}
+
+	o := NewOverlay(os.DirFS(baseRoot), t.TempDir()).(wasmexec.HostSymlinkFS)
+
+	if err := o.Symlink("target.txt", "link.txt"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	got, err := o.Readlink("link.txt")
+	if err != nil {
+		t.Fatalf("Readlink: %v", err)
+	}
+	if got != "target.txt" {
+		t.Fatalf("Readlink = %q, want %q", got, "target.txt")
+	}
+
+	if err := o.Link("target.txt", "alias.txt"); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	data, err := fs.ReadFile(o.(wasmexec.HostFS), "alias.txt")
+	if err != nil {
+		t.Fatalf("ReadFile(alias): %v", err)
+	}
+	if string(data) != "data" {
+		t.Fatalf("alias content = %q, want %q", data, "data")
+	}
+}
+
+func TestOverlayTruncateChtimes(t *testing.T) {
+	baseRoot := t.TempDir()
+	if err := os.WriteFile(baseRoot+"/file.txt", []byte("hello world"), 0o644); err != nil {
+		t.Fatalf("seed base file: %v", err)
+	}
+
+	o := NewOverlay(os.DirFS(baseRoot), t.TempDir()).(wasmexec.HostWriteFS)
+
+	if err := o.Truncate("file.txt", 5); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+	got, err := fs.ReadFile(o, "file.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("content = %q, want %q", got, "hello")
+	}
+
+	mtime := time.Unix(1000000, 0)
+	if err := o.Chtimes("file.txt", mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	info, err := o.Stat("file.txt")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Fatalf("ModTime = %v, want %v", info.ModTime(), mtime)
+	}
+}
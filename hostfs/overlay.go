@@ -0,0 +1,310 @@
+package hostfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prep/wasmexec"
+)
+
+// overlayFS makes a read-only fs.FS (embed.FS, zip.Reader, a tar-derived
+// FS, ...) appear writable by copying files into a scratch directory on
+// first write, like a classic overlay/union filesystem. Reads fall through
+// to the scratch directory first, then to base; writes always land in the
+// scratch directory.
+type overlayFS struct {
+	base    fs.FS
+	writeFS *dirFS
+
+	mu      sync.Mutex
+	deleted map[string]struct{}
+}
+
+// NewOverlay returns a HostFS that serves reads from base and copy-on-write
+// writes from writeRoot, making any read-only fs.FS writable without
+// mutating it.
+func NewOverlay(base fs.FS, writeRoot string) wasmexec.HostFS {
+	return &overlayFS{
+		base:    base,
+		writeFS: &dirFS{root: writeRoot},
+		deleted: make(map[string]struct{}),
+	}
+}
+
+func (o *overlayFS) isDeleted(name string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, ok := o.deleted[name]
+	return ok
+}
+
+func (o *overlayFS) setDeleted(name string, deleted bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if deleted {
+		o.deleted[name] = struct{}{}
+	} else {
+		delete(o.deleted, name)
+	}
+}
+
+func (o *overlayFS) hasOverlay(name string) bool {
+	_, err := o.writeFS.Lstat(name)
+	return err == nil
+}
+
+// ensureParentDir makes sure name's parent directory exists in the scratch
+// directory, so a file copied or created below it has somewhere to land.
+func (o *overlayFS) ensureParentDir(name string) error {
+	parent := path.Dir(name)
+	if parent == "." {
+		return nil
+	}
+	dir, err := o.writeFS.join(parent)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, 0o755)
+}
+
+// copyFromBase copies name from base into the scratch directory if it
+// isn't already there, so subsequent writes through writeFS see it.
+func (o *overlayFS) copyFromBase(name string) error {
+	if o.hasOverlay(name) {
+		return nil
+	}
+
+	f, err := o.base.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		dir, err := o.writeFS.join(name)
+		if err != nil {
+			return err
+		}
+		return os.MkdirAll(dir, 0o755)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if err := o.ensureParentDir(name); err != nil {
+		return err
+	}
+
+	dst, err := o.writeFS.join(name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, info.Mode().Perm())
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	if o.isDeleted(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if o.hasOverlay(name) {
+		return o.writeFS.Open(name)
+	}
+	return o.base.Open(name)
+}
+
+func (o *overlayFS) Stat(name string) (fs.FileInfo, error) {
+	if o.isDeleted(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	if o.hasOverlay(name) {
+		return o.writeFS.Stat(name)
+	}
+	return wrapStat(fs.Stat(o.base, name))
+}
+
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if o.isDeleted(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	byName := make(map[string]fs.DirEntry)
+
+	if baseEntries, err := fs.ReadDir(o.base, name); err == nil {
+		for _, entry := range baseEntries {
+			byName[entry.Name()] = entry
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if overlayEntries, err := o.writeFS.ReadDir(name); err == nil {
+		for _, entry := range overlayEntries {
+			byName[entry.Name()] = entry
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	entries := make([]fs.DirEntry, 0, len(byName))
+	for childName, entry := range byName {
+		if o.isDeleted(path.Join(name, childName)) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (o *overlayFS) Chmod(name string, mode fs.FileMode) error {
+	if err := o.copyFromBase(name); err != nil {
+		return err
+	}
+	return o.writeFS.Chmod(name, mode)
+}
+
+func (o *overlayFS) OpenFile(name string, flag int, perm fs.FileMode) (wasmexec.File, error) {
+	if flag&os.O_TRUNC == 0 {
+		if err := o.copyFromBase(name); err != nil {
+			return nil, err
+		}
+	} else if err := o.ensureParentDir(name); err != nil {
+		return nil, err
+	}
+
+	file, err := o.writeFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	o.setDeleted(name, false)
+	return file, nil
+}
+
+func (o *overlayFS) Mkdir(name string, perm fs.FileMode) error {
+	if err := o.ensureParentDir(name); err != nil {
+		return err
+	}
+	if err := o.writeFS.Mkdir(name, perm); err != nil {
+		return err
+	}
+	o.setDeleted(name, false)
+	return nil
+}
+
+func (o *overlayFS) Remove(name string) error {
+	err := o.writeFS.Remove(name)
+	if err == nil {
+		o.setDeleted(name, true)
+		return nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// Not in the scratch dir: only a tombstone if it actually exists in
+	// base, otherwise this is a genuine ENOENT.
+	if o.isDeleted(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	info, statErr := fs.Stat(o.base, name)
+	if statErr != nil {
+		return statErr
+	}
+	if info.IsDir() {
+		entries, err := o.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	o.setDeleted(name, true)
+	return nil
+}
+
+func (o *overlayFS) Rename(oldname, newname string) error {
+	if err := o.copyFromBase(oldname); err != nil {
+		return err
+	}
+	if err := o.ensureParentDir(newname); err != nil {
+		return err
+	}
+	if err := o.writeFS.Rename(oldname, newname); err != nil {
+		return err
+	}
+	o.setDeleted(oldname, true)
+	o.setDeleted(newname, false)
+	return nil
+}
+
+func (o *overlayFS) Truncate(name string, size int64) error {
+	if err := o.copyFromBase(name); err != nil {
+		return err
+	}
+	return o.writeFS.Truncate(name, size)
+}
+
+func (o *overlayFS) Chtimes(name string, atime, mtime time.Time) error {
+	if err := o.copyFromBase(name); err != nil {
+		return err
+	}
+	return o.writeFS.Chtimes(name, atime, mtime)
+}
+
+func (o *overlayFS) Lstat(name string) (fs.FileInfo, error) {
+	if o.isDeleted(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrNotExist}
+	}
+	if o.hasOverlay(name) {
+		return o.writeFS.Lstat(name)
+	}
+	// base is a plain fs.FS with no symlink concept, so falling back to
+	// Stat is equivalent to Lstat for anything it serves.
+	return wrapStat(fs.Stat(o.base, name))
+}
+
+func (o *overlayFS) Symlink(oldname, newname string) error {
+	if err := o.ensureParentDir(newname); err != nil {
+		return err
+	}
+	if err := o.writeFS.Symlink(oldname, newname); err != nil {
+		return err
+	}
+	o.setDeleted(newname, false)
+	return nil
+}
+
+func (o *overlayFS) Readlink(name string) (string, error) {
+	if o.hasOverlay(name) {
+		return o.writeFS.Readlink(name)
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+}
+
+func (o *overlayFS) Link(oldname, newname string) error {
+	if err := o.copyFromBase(oldname); err != nil {
+		return err
+	}
+	if err := o.ensureParentDir(newname); err != nil {
+		return err
+	}
+	if err := o.writeFS.Link(oldname, newname); err != nil {
+		return err
+	}
+	o.setDeleted(newname, false)
+	return nil
+}
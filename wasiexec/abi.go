@@ -0,0 +1,425 @@
+package wasiexec
+
+import (
+	"encoding/binary"
+	"io/fs"
+
+	"github.com/prep/wasmexec"
+)
+
+// The wasm32 wasi_snapshot_preview1 ABI passes only i32/i64 values and
+// guest-memory offsets across the host/guest boundary; every exported
+// function below decodes its arguments out of Memory and encodes its
+// results back into it, then delegates to the corresponding logical method
+// on Module. This file is the only place in the package that knows the wire
+// layout of iovecs, filestat_t, and dirent_t.
+
+// ciovec/iovec are identical on the wire: a guest pointer followed by a
+// byte length, 4 bytes each for wasm32.
+const ciovecSize = 8
+
+// getUint32 reads a little-endian u32 out of mem at offset.
+func getUint32(mem Memory, offset uint32) (uint32, error) {
+	var buf [4]byte
+	if err := mem.Read(offset, buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+// putUint32 writes v as a little-endian u32 into mem at offset.
+func putUint32(mem Memory, offset, v uint32) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return mem.Write(offset, buf[:])
+}
+
+// putUint64 writes v as a little-endian u64 into mem at offset.
+func putUint64(mem Memory, offset uint32, v uint64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return mem.Write(offset, buf[:])
+}
+
+// readString reads length bytes at ptr and returns them as a string, the
+// form every *_path argument takes on the wire (a pointer/length pair with
+// no NUL terminator).
+func readString(mem Memory, ptr, length uint32) (string, error) {
+	buf := make([]byte, length)
+	if err := mem.Read(ptr, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// readIOVs decodes count ciovec/iovec structs starting at ptr into the
+// guest buffers they describe, for handing to FDWrite/FDRead.
+func readIOVs(mem Memory, ptr, count uint32) ([][]byte, error) {
+	iovs := make([][]byte, count)
+	for i := uint32(0); i < count; i++ {
+		base := ptr + i*ciovecSize
+		bufPtr, err := getUint32(mem, base)
+		if err != nil {
+			return nil, err
+		}
+		bufLen, err := getUint32(mem, base+4)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, bufLen)
+		if err := mem.Read(bufPtr, buf); err != nil {
+			return nil, err
+		}
+		iovs[i] = buf
+	}
+	return iovs, nil
+}
+
+// writeIOVs copies the (possibly short) contents read into iovs back out to
+// the guest buffers readIOVs decoded them from, for FDRead.
+func writeIOVs(mem Memory, ptr, count uint32, iovs [][]byte) error {
+	for i := uint32(0); i < count; i++ {
+		base := ptr + i*ciovecSize
+		bufPtr, err := getUint32(mem, base)
+		if err != nil {
+			return err
+		}
+		if err := mem.Write(bufPtr, iovs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ABIFDWrite implements the raw fd_write import: iovsPtr/iovsLen describe
+// the guest's ciovec array, and the number of bytes written is stored at
+// nwrittenPtr.
+func ABIFDWrite(mem Memory, m *Module, f fd, iovsPtr, iovsLen, nwrittenPtr uint32) Errno {
+	iovs, err := readIOVs(mem, iovsPtr, iovsLen)
+	if err != nil {
+		return ErrnoInval
+	}
+
+	written, errno := m.FDWrite(f, iovs)
+	if err := putUint32(mem, nwrittenPtr, written); err != nil {
+		return ErrnoInval
+	}
+	return errno
+}
+
+// ABIFDRead implements the raw fd_read import: iovsPtr/iovsLen describe the
+// guest's iovec array, and the number of bytes read is stored at nreadPtr.
+func ABIFDRead(mem Memory, m *Module, f fd, iovsPtr, iovsLen, nreadPtr uint32) Errno {
+	iovs, err := readIOVs(mem, iovsPtr, iovsLen)
+	if err != nil {
+		return ErrnoInval
+	}
+
+	read, errno := m.FDRead(f, iovs)
+	if err := writeIOVs(mem, iovsPtr, iovsLen, iovs); err != nil {
+		return ErrnoInval
+	}
+	if err := putUint32(mem, nreadPtr, read); err != nil {
+		return ErrnoInval
+	}
+	return errno
+}
+
+// ABIFDClose implements the raw fd_close import. It takes no guest memory,
+// so it is a thin rename of the logical method.
+func ABIFDClose(m *Module, f fd) Errno {
+	return m.FDClose(f)
+}
+
+// ABIFDSeek implements the raw fd_seek import, storing the resulting offset
+// at newOffsetPtr.
+func ABIFDSeek(mem Memory, m *Module, f fd, offset int64, whence uint8, newOffsetPtr uint32) Errno {
+	newOffset, errno := m.FDSeek(f, offset, Whence(whence))
+	if err := putUint64(mem, newOffsetPtr, newOffset); err != nil {
+		return ErrnoInval
+	}
+	return errno
+}
+
+// ABIPathOpen implements the raw path_open import: pathPtr/pathLen name the
+// guest's path buffer, and the new fd is stored at fdPtr.
+func ABIPathOpen(mem Memory, m *Module, pathPtr, pathLen uint32, oflags OFlags, fdPtr uint32) Errno {
+	path, err := readString(mem, pathPtr, pathLen)
+	if err != nil {
+		return ErrnoInval
+	}
+
+	newFD, errno := m.PathOpen(path, oflags)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	if err := putUint32(mem, fdPtr, uint32(newFD)); err != nil {
+		return ErrnoInval
+	}
+	return ErrnoSuccess
+}
+
+// filetype mirrors the wasi_snapshot_preview1 filetype enum used in
+// filestat_t and dirent_t.
+type filetype uint8
+
+const (
+	filetypeUnknown      filetype = 0
+	filetypeDirectory    filetype = 3
+	filetypeRegularFile  filetype = 4
+	filetypeSymbolicLink filetype = 7
+	filetypeSize                  = 64
+	direntHeaderSize              = 24
+)
+
+// filetypeFromFileInfo maps an fs.FileInfo's mode to the closest
+// wasi_snapshot_preview1 filetype.
+func filetypeFromFileInfo(info fs.FileInfo) filetype {
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		return filetypeSymbolicLink
+	case info.IsDir():
+		return filetypeDirectory
+	case info.Mode().IsRegular():
+		return filetypeRegularFile
+	default:
+		return filetypeUnknown
+	}
+}
+
+// putFilestat encodes info as a wasi_snapshot_preview1 filestat_t (64
+// bytes) at ptr, preferring the real dev/ino/nlink/timestamps a
+// wasmexec.HostFileInfo reports over the zeroed defaults.
+func putFilestat(mem Memory, ptr uint32, info fs.FileInfo) error {
+	stat := &wasmexec.StatInfo{
+		Nlink: 1,
+		Mtime: info.ModTime(),
+	}
+	if hfi, ok := info.(wasmexec.HostFileInfo); ok {
+		if s, ok := hfi.Sys().(*wasmexec.StatInfo); ok && s != nil {
+			stat = s
+		}
+	}
+	if stat.Atime.IsZero() {
+		stat.Atime = info.ModTime()
+	}
+	if stat.Ctime.IsZero() {
+		stat.Ctime = info.ModTime()
+	}
+
+	buf := make([]byte, filetypeSize)
+	binary.LittleEndian.PutUint64(buf[0:8], stat.Dev)
+	binary.LittleEndian.PutUint64(buf[8:16], stat.Ino)
+	buf[16] = byte(filetypeFromFileInfo(info))
+	binary.LittleEndian.PutUint64(buf[24:32], stat.Nlink)
+	binary.LittleEndian.PutUint64(buf[32:40], uint64(info.Size()))
+	binary.LittleEndian.PutUint64(buf[40:48], uint64(stat.Atime.UnixNano()))
+	binary.LittleEndian.PutUint64(buf[48:56], uint64(stat.Mtime.UnixNano()))
+	binary.LittleEndian.PutUint64(buf[56:64], uint64(stat.Ctime.UnixNano()))
+
+	return mem.Write(ptr, buf)
+}
+
+// ABIPathFilestatGet implements the raw path_filestat_get import, encoding
+// the result as a filestat_t at statPtr.
+func ABIPathFilestatGet(mem Memory, m *Module, pathPtr, pathLen uint32, statPtr uint32) Errno {
+	path, err := readString(mem, pathPtr, pathLen)
+	if err != nil {
+		return ErrnoInval
+	}
+
+	info, errno := m.PathFilestatGet(path)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	if err := putFilestat(mem, statPtr, info); err != nil {
+		return ErrnoInval
+	}
+	return ErrnoSuccess
+}
+
+// ABIPathUnlinkFile implements the raw path_unlink_file import.
+func ABIPathUnlinkFile(mem Memory, m *Module, pathPtr, pathLen uint32) Errno {
+	path, err := readString(mem, pathPtr, pathLen)
+	if err != nil {
+		return ErrnoInval
+	}
+	return m.PathUnlinkFile(path)
+}
+
+// ABIPathCreateDirectory implements the raw path_create_directory import.
+func ABIPathCreateDirectory(mem Memory, m *Module, pathPtr, pathLen uint32) Errno {
+	path, err := readString(mem, pathPtr, pathLen)
+	if err != nil {
+		return ErrnoInval
+	}
+	return m.PathCreateDirectory(path)
+}
+
+// ABIPathRename implements the raw path_rename import.
+func ABIPathRename(mem Memory, m *Module, oldPtr, oldLen, newPtr, newLen uint32) Errno {
+	oldpath, err := readString(mem, oldPtr, oldLen)
+	if err != nil {
+		return ErrnoInval
+	}
+	newpath, err := readString(mem, newPtr, newLen)
+	if err != nil {
+		return ErrnoInval
+	}
+	return m.PathRename(oldpath, newpath)
+}
+
+// ABIPathSymlink implements the raw path_symlink import.
+func ABIPathSymlink(mem Memory, m *Module, oldPtr, oldLen, newPtr, newLen uint32) Errno {
+	oldpath, err := readString(mem, oldPtr, oldLen)
+	if err != nil {
+		return ErrnoInval
+	}
+	newpath, err := readString(mem, newPtr, newLen)
+	if err != nil {
+		return ErrnoInval
+	}
+	return m.PathSymlink(oldpath, newpath)
+}
+
+// ABIFDReaddir implements the raw fd_readdir import. Every call re-reads
+// the full listing through Module.FDReaddir and treats cookie as the index
+// of the first entry to return (each record's d_next is its index plus
+// one). It writes as many whole dirent_t+name records as fit in bufLen,
+// reporting the number of bytes actually written at bufUsedPtr.
+func ABIFDReaddir(mem Memory, m *Module, f fd, bufPtr, bufLen uint32, cookie uint64, bufUsedPtr uint32) Errno {
+	entries, errno := m.FDReaddir(f)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+
+	var written uint32
+	for i, entry := range entries {
+		if uint64(i) < cookie {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		name := entry.Name()
+		recLen := uint32(direntHeaderSize + len(name))
+		if written+recLen > bufLen {
+			break
+		}
+
+		rec := make([]byte, recLen)
+		binary.LittleEndian.PutUint64(rec[0:8], uint64(i)+1) // d_next cookie
+		binary.LittleEndian.PutUint64(rec[8:16], 0)          // d_ino
+		binary.LittleEndian.PutUint32(rec[16:20], uint32(len(name)))
+		rec[20] = byte(filetypeFromFileInfo(info))
+		copy(rec[direntHeaderSize:], name)
+
+		if err := mem.Write(bufPtr+written, rec); err != nil {
+			return ErrnoInval
+		}
+		written += recLen
+	}
+
+	if err := putUint32(mem, bufUsedPtr, written); err != nil {
+		return ErrnoInval
+	}
+	return ErrnoSuccess
+}
+
+// ABIClockTimeGet implements the raw clock_time_get import, storing the
+// current time (nanoseconds since the Unix epoch) at timePtr.
+func ABIClockTimeGet(mem Memory, m *Module, timePtr uint32) Errno {
+	if err := putUint64(mem, timePtr, m.ClockTimeGet()); err != nil {
+		return ErrnoInval
+	}
+	return ErrnoSuccess
+}
+
+// putStringVector writes strs as args_get/environ_get expect: a pointer
+// table of len(strs) u32s at ptrPtr pointing into a packed, NUL-terminated
+// byte blob written at bufPtr.
+func putStringVector(mem Memory, ptrPtr, bufPtr uint32, strs []string) error {
+	offset := bufPtr
+	for i, s := range strs {
+		if err := putUint32(mem, ptrPtr+uint32(i)*4, offset); err != nil {
+			return err
+		}
+		if err := mem.Write(offset, append([]byte(s), 0)); err != nil {
+			return err
+		}
+		offset += uint32(len(s)) + 1
+	}
+	return nil
+}
+
+// stringVectorSizes reports the (count, packed-byte-size-including-NULs)
+// pair args_sizes_get/environ_sizes_get report ahead of the _get call that
+// actually copies the data, so the guest can size its buffers.
+func stringVectorSizes(strs []string) (count, size uint32) {
+	for _, s := range strs {
+		size += uint32(len(s)) + 1
+	}
+	return uint32(len(strs)), size
+}
+
+// ABIArgsSizesGet implements the raw args_sizes_get import.
+func ABIArgsSizesGet(mem Memory, m *Module, countPtr, sizePtr uint32) Errno {
+	count, size := stringVectorSizes(m.ArgsGet())
+	if err := putUint32(mem, countPtr, count); err != nil {
+		return ErrnoInval
+	}
+	if err := putUint32(mem, sizePtr, size); err != nil {
+		return ErrnoInval
+	}
+	return ErrnoSuccess
+}
+
+// ABIArgsGet implements the raw args_get import.
+func ABIArgsGet(mem Memory, m *Module, ptrPtr, bufPtr uint32) Errno {
+	if err := putStringVector(mem, ptrPtr, bufPtr, m.ArgsGet()); err != nil {
+		return ErrnoInval
+	}
+	return ErrnoSuccess
+}
+
+// ABIEnvironSizesGet implements the raw environ_sizes_get import.
+func ABIEnvironSizesGet(mem Memory, m *Module, countPtr, sizePtr uint32) Errno {
+	count, size := stringVectorSizes(m.EnvironGet())
+	if err := putUint32(mem, countPtr, count); err != nil {
+		return ErrnoInval
+	}
+	if err := putUint32(mem, sizePtr, size); err != nil {
+		return ErrnoInval
+	}
+	return ErrnoSuccess
+}
+
+// ABIEnvironGet implements the raw environ_get import.
+func ABIEnvironGet(mem Memory, m *Module, ptrPtr, bufPtr uint32) Errno {
+	if err := putStringVector(mem, ptrPtr, bufPtr, m.EnvironGet()); err != nil {
+		return ErrnoInval
+	}
+	return ErrnoSuccess
+}
+
+// ABIRandomGet implements the raw random_get import, filling bufLen bytes
+// at bufPtr with random data.
+func ABIRandomGet(mem Memory, m *Module, bufPtr, bufLen uint32) Errno {
+	buf := make([]byte, bufLen)
+	if errno := m.RandomGet(buf); errno != ErrnoSuccess {
+		return errno
+	}
+	if err := mem.Write(bufPtr, buf); err != nil {
+		return ErrnoInval
+	}
+	return ErrnoSuccess
+}
+
+// ABIProcExit implements the raw proc_exit import. It takes no guest
+// memory, so it is a thin rename of the logical method.
+func ABIProcExit(m *Module, code uint32) uint32 {
+	return m.ProcExit(code)
+}
@@ -0,0 +1,414 @@
+// Package wasiexec implements the wasi_snapshot_preview1 host import
+// namespace as an alternative to the GOOS=js shim in the parent wasmexec
+// package. Upstream wazero is moving its own test matrix from GOOS=js to
+// GOOS=wasip1 (see the wazero PR that drops the gojs_stdlib job), so guest
+// programs should increasingly be compiled with:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -o main.wasm .
+//
+// and run through this package instead of the wasmexec gojs shim. Both
+// packages share the same wasmexec.HostFS/HostFileInfo abstraction, so a
+// single host filesystem implementation backs either mode.
+package wasiexec
+
+import (
+	"crypto/rand"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prep/wasmexec"
+)
+
+// ErrOutOfRange is returned by a Memory implementation when an offset/length
+// pair falls outside the guest's linear memory.
+var ErrOutOfRange = errors.New("wasiexec: offset out of range")
+
+// Errno mirrors the wasi_snapshot_preview1 errno enum. Only the subset the
+// host side needs to return is named here; see the WASI spec for the rest.
+type Errno uint32
+
+// The subset of WASI errno values this package returns.
+const (
+	ErrnoSuccess  Errno = 0
+	ErrnoBadf     Errno = 8
+	ErrnoExist    Errno = 20
+	ErrnoInval    Errno = 28
+	ErrnoIsdir    Errno = 31
+	ErrnoNoent    Errno = 44
+	ErrnoNosys    Errno = 52
+	ErrnoNotdir   Errno = 54
+	ErrnoNotempty Errno = 55
+)
+
+// Memory is the minimal view into linear memory a wasi_snapshot_preview1
+// implementation needs. Each of wasmtime-go, wazero, and wasmer-go exposes
+// its own module memory type, and the ABI layer only ever goes through
+// Memory. This package provides the wazero adapter (wazero.go); it has no
+// adapter for wasmtime-go or wasmer-go.
+type Memory interface {
+	// Read copies len(p) bytes from the guest's linear memory at offset
+	// into p.
+	Read(offset uint32, p []byte) error
+
+	// Write copies p into the guest's linear memory at offset.
+	Write(offset uint32, p []byte) error
+}
+
+// Module holds the state one instantiated guest needs to service the
+// wasi_snapshot_preview1 imports: its host filesystem, open file
+// descriptors, and the arguments/environment to hand back from args_get and
+// environ_get.
+type Module struct {
+	hostFS wasmexec.HostFS
+	fds    *fdTable
+
+	args    []string
+	environ []string
+}
+
+// NewModule returns a Module that serves wasi_snapshot_preview1 calls
+// against hostFS, exposing args and environ to the guest via args_get and
+// environ_get.
+func NewModule(hostFS wasmexec.HostFS, args, environ []string) *Module {
+	return &Module{
+		hostFS:  hostFS,
+		fds:     newFDTable(),
+		args:    args,
+		environ: environ,
+	}
+}
+
+// fd is the WASI file descriptor type.
+type fd = int32
+
+// openFile tracks the state needed to service reads/writes/seeks/readdir
+// against a single open WASI file descriptor, mirroring the fdTable in the
+// parent package's fs.go.
+type openFile struct {
+	path   string
+	file   wasmexec.File
+	offset int64
+}
+
+// fdTable hands out WASI file descriptors and tracks their openFiles,
+// mirroring the fdTable in the parent package's fs.go, including its
+// sync.Mutex guard against concurrent access from the embedding runtime.
+type fdTable struct {
+	mu    sync.Mutex
+	next  fd
+	files map[fd]*openFile
+}
+
+// newFDTable returns an empty fdTable with descriptors starting at 3,
+// leaving 0-2 reserved for stdin/stdout/stderr.
+func newFDTable() *fdTable {
+	return &fdTable{
+		next:  3,
+		files: make(map[fd]*openFile),
+	}
+}
+
+func (t *fdTable) add(of *openFile) fd {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f := t.next
+	t.next++
+	t.files[f] = of
+	return f
+}
+
+func (t *fdTable) get(f fd) (*openFile, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	of, ok := t.files[f]
+	return of, ok
+}
+
+func (t *fdTable) remove(f fd) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.files, f)
+}
+
+// errnoFromError maps a host error to the closest wasi_snapshot_preview1
+// errno. It unwraps a wrapped syscall.Errno to recover the precise code,
+// mirroring fsErrorResponse in the parent package's fs.go, and falls back
+// to ErrnoInval for anything unrecognized.
+func errnoFromError(err error) Errno {
+	if err == nil {
+		return ErrnoSuccess
+	}
+	if os.IsNotExist(err) {
+		return ErrnoNoent
+	}
+
+	if baseErr := errors.Unwrap(err); baseErr != nil {
+		err = baseErr
+	}
+	if errnoErr, ok := err.(syscall.Errno); ok {
+		switch errnoErr {
+		case syscall.EEXIST:
+			return ErrnoExist
+		case syscall.EISDIR:
+			return ErrnoIsdir
+		case syscall.ENOTDIR:
+			return ErrnoNotdir
+		case syscall.ENOTEMPTY:
+			return ErrnoNotempty
+		case syscall.EBADF:
+			return ErrnoBadf
+		case syscall.ENOSYS:
+			return ErrnoNosys
+		case syscall.ENOENT:
+			return ErrnoNoent
+		}
+	}
+	return ErrnoInval
+}
+
+// FDWrite implements the fd_write import: it writes iovs (already resolved
+// host-side from the guest's iovec array) to the open file fd and reports
+// the number of bytes written.
+func (m *Module) FDWrite(f fd, iovs [][]byte) (uint32, Errno) {
+	of, ok := m.fds.get(f)
+	if !ok {
+		return 0, ErrnoBadf
+	}
+
+	var written uint32
+	for _, iov := range iovs {
+		n, err := of.file.Write(iov)
+		written += uint32(n)
+		if err != nil {
+			return written, errnoFromError(err)
+		}
+	}
+	return written, ErrnoSuccess
+}
+
+// FDRead implements the fd_read import: it reads into iovs from the open
+// file fd and reports the number of bytes read.
+func (m *Module) FDRead(f fd, iovs [][]byte) (uint32, Errno) {
+	of, ok := m.fds.get(f)
+	if !ok {
+		return 0, ErrnoBadf
+	}
+
+	var read uint32
+	for _, iov := range iovs {
+		n, err := of.file.Read(iov)
+		read += uint32(n)
+		of.offset += int64(n)
+		if err != nil {
+			return read, errnoFromError(err)
+		}
+	}
+	return read, ErrnoSuccess
+}
+
+// FDClose implements the fd_close import.
+func (m *Module) FDClose(f fd) Errno {
+	of, ok := m.fds.get(f)
+	if !ok {
+		return ErrnoBadf
+	}
+	m.fds.remove(f)
+
+	if err := of.file.Close(); err != nil {
+		return errnoFromError(err)
+	}
+	return ErrnoSuccess
+}
+
+// Whence mirrors the wasi_snapshot_preview1 whence enum used by fd_seek.
+type Whence uint8
+
+// The whence values fd_seek accepts.
+const (
+	WhenceSet Whence = iota
+	WhenceCur
+	WhenceEnd
+)
+
+// FDSeek implements the fd_seek import.
+func (m *Module) FDSeek(f fd, offset int64, whence Whence) (uint64, Errno) {
+	of, ok := m.fds.get(f)
+	if !ok {
+		return 0, ErrnoBadf
+	}
+
+	newOffset, err := of.file.Seek(offset, int(whence))
+	if err != nil {
+		return 0, errnoFromError(err)
+	}
+	of.offset = newOffset
+	return uint64(newOffset), ErrnoSuccess
+}
+
+// OFlags mirrors the oflags bitset path_open accepts.
+type OFlags uint16
+
+// The oflags bits path_open accepts.
+const (
+	OFlagCreat OFlags = 1 << iota
+	OFlagDirectory
+	OFlagExcl
+	OFlagTrunc
+)
+
+// PathOpen implements the path_open import, opening path relative to the
+// module's HostFS and returning a new fd.
+func (m *Module) PathOpen(path string, oflags OFlags) (fd, Errno) {
+	if oflags&OFlagCreat != 0 || oflags&OFlagTrunc != 0 {
+		writeFS, ok := m.hostFS.(wasmexec.HostWriteFS)
+		if !ok {
+			return 0, ErrnoNosys
+		}
+
+		flags := os.O_RDWR | osOpenFlags(oflags)
+		file, err := writeFS.OpenFile(path, flags, 0o644)
+		if err != nil {
+			return 0, errnoFromError(err)
+		}
+		return m.fds.add(&openFile{path: path, file: file}), ErrnoSuccess
+	}
+
+	f, err := m.hostFS.Open(path)
+	if err != nil {
+		return 0, errnoFromError(err)
+	}
+	file, ok := f.(wasmexec.File)
+	if !ok {
+		return 0, ErrnoNosys
+	}
+	return m.fds.add(&openFile{path: path, file: file}), ErrnoSuccess
+}
+
+// PathFilestatGet implements the path_filestat_get import.
+func (m *Module) PathFilestatGet(path string) (fs.FileInfo, Errno) {
+	info, err := m.hostFS.Stat(path)
+	if err != nil {
+		return nil, errnoFromError(err)
+	}
+	return info, ErrnoSuccess
+}
+
+// PathUnlinkFile implements the path_unlink_file import.
+func (m *Module) PathUnlinkFile(path string) Errno {
+	writeFS, ok := m.hostFS.(wasmexec.HostWriteFS)
+	if !ok {
+		return ErrnoNosys
+	}
+	if err := writeFS.Remove(path); err != nil {
+		return errnoFromError(err)
+	}
+	return ErrnoSuccess
+}
+
+// PathCreateDirectory implements the path_create_directory import.
+func (m *Module) PathCreateDirectory(path string) Errno {
+	writeFS, ok := m.hostFS.(wasmexec.HostWriteFS)
+	if !ok {
+		return ErrnoNosys
+	}
+	if err := writeFS.Mkdir(path, 0o755); err != nil {
+		return errnoFromError(err)
+	}
+	return ErrnoSuccess
+}
+
+// PathRename implements the path_rename import.
+func (m *Module) PathRename(oldpath, newpath string) Errno {
+	writeFS, ok := m.hostFS.(wasmexec.HostWriteFS)
+	if !ok {
+		return ErrnoNosys
+	}
+	if err := writeFS.Rename(oldpath, newpath); err != nil {
+		return errnoFromError(err)
+	}
+	return ErrnoSuccess
+}
+
+// PathSymlink implements the path_symlink import.
+func (m *Module) PathSymlink(oldpath, newpath string) Errno {
+	symlinkFS, ok := m.hostFS.(wasmexec.HostSymlinkFS)
+	if !ok {
+		return ErrnoNosys
+	}
+	if err := symlinkFS.Symlink(oldpath, newpath); err != nil {
+		return errnoFromError(err)
+	}
+	return ErrnoSuccess
+}
+
+// FDReaddir implements the fd_readdir import.
+func (m *Module) FDReaddir(f fd) ([]fs.DirEntry, Errno) {
+	of, ok := m.fds.get(f)
+	if !ok {
+		return nil, ErrnoBadf
+	}
+
+	entries, err := m.hostFS.ReadDir(of.path)
+	if err != nil {
+		return nil, errnoFromError(err)
+	}
+	return entries, ErrnoSuccess
+}
+
+// ClockTimeGet implements the clock_time_get import, returning the current
+// wall-clock time in nanoseconds since the Unix epoch.
+func (m *Module) ClockTimeGet() uint64 {
+	return uint64(time.Now().UnixNano())
+}
+
+// ArgsGet returns the guest's argv, matching what args_get copies into
+// linear memory.
+func (m *Module) ArgsGet() []string {
+	return m.args
+}
+
+// EnvironGet returns the guest's environment, matching what environ_get
+// copies into linear memory.
+func (m *Module) EnvironGet() []string {
+	return m.environ
+}
+
+// RandomGet implements the random_get import, filling p with
+// cryptographically random bytes. crypto/rand and the Go runtime's own
+// internal seeding depend on this.
+func (m *Module) RandomGet(p []byte) Errno {
+	if _, err := rand.Read(p); err != nil {
+		return ErrnoInval
+	}
+	return ErrnoSuccess
+}
+
+// ProcExit implements the proc_exit import. It has no host-side work to do
+// beyond reporting the guest's requested exit code back to the caller,
+// which is expected to stop running the module.
+func (m *Module) ProcExit(code uint32) uint32 {
+	return code
+}
+
+// osOpenFlags translates the subset of oflags PathOpen needs into the
+// equivalent os.O_* bits expected by HostWriteFS.OpenFile.
+func osOpenFlags(oflags OFlags) int {
+	var flags int
+	if oflags&OFlagCreat != 0 {
+		flags |= os.O_CREATE
+	}
+	if oflags&OFlagExcl != 0 {
+		flags |= os.O_EXCL
+	}
+	if oflags&OFlagTrunc != 0 {
+		flags |= os.O_TRUNC
+	}
+	return flags
+}
@@ -0,0 +1,150 @@
+package wasiexec_test
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/prep/wasmexec/hostfs"
+	"github.com/prep/wasmexec/wasiexec"
+)
+
+// fakeMemory is a minimal Memory backed by a plain byte slice, standing in
+// for a wazero/wasmtime/wasmer guest's linear memory in tests that exercise
+// the ABI decoding layer without a real wasm runtime.
+type fakeMemory struct {
+	data []byte
+}
+
+func newFakeMemory(size int) *fakeMemory {
+	return &fakeMemory{data: make([]byte, size)}
+}
+
+func (m *fakeMemory) Read(offset uint32, p []byte) error {
+	copy(p, m.data[offset:])
+	return nil
+}
+
+func (m *fakeMemory) Write(offset uint32, p []byte) error {
+	copy(m.data[offset:], p)
+	return nil
+}
+
+func TestABIPathOpenFDWriteFDRead(t *testing.T) {
+	root := t.TempDir()
+	m := wasiexec.NewModule(hostfs.New(root), nil, nil)
+	mem := newFakeMemory(1 << 16)
+
+	const pathPtr, pathLen = 0, 8
+	copy(mem.data[pathPtr:], "file.txt")
+
+	const fdPtr = 100
+	if errno := wasiexec.ABIPathOpen(mem, m, pathPtr, pathLen, wasiexec.OFlagCreat, fdPtr); errno != wasiexec.ErrnoSuccess {
+		t.Fatalf("ABIPathOpen = %v, want success", errno)
+	}
+	newFD := int32(binary.LittleEndian.Uint32(mem.data[fdPtr:]))
+
+	const iovBase, dataPtr, nwrittenPtr = 200, 300, 400
+	data := []byte("hello wasi")
+	copy(mem.data[dataPtr:], data)
+	binary.LittleEndian.PutUint32(mem.data[iovBase:], dataPtr)
+	binary.LittleEndian.PutUint32(mem.data[iovBase+4:], uint32(len(data)))
+
+	if errno := wasiexec.ABIFDWrite(mem, m, newFD, iovBase, 1, nwrittenPtr); errno != wasiexec.ErrnoSuccess {
+		t.Fatalf("ABIFDWrite = %v, want success", errno)
+	}
+	if written := binary.LittleEndian.Uint32(mem.data[nwrittenPtr:]); written != uint32(len(data)) {
+		t.Fatalf("nwritten = %d, want %d", written, len(data))
+	}
+	if errno := wasiexec.ABIFDClose(m, newFD); errno != wasiexec.ErrnoSuccess {
+		t.Fatalf("ABIFDClose = %v, want success", errno)
+	}
+
+	got, err := os.ReadFile(root + "/file.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Fatalf("file content = %q, want %q", got, data)
+	}
+
+	// Read it back through the ABI too.
+	if errno := wasiexec.ABIPathOpen(mem, m, pathPtr, pathLen, 0, fdPtr); errno != wasiexec.ErrnoSuccess {
+		t.Fatalf("ABIPathOpen(read) = %v, want success", errno)
+	}
+	readFD := int32(binary.LittleEndian.Uint32(mem.data[fdPtr:]))
+
+	const readBufPtr, nreadPtr = 500, 600
+	binary.LittleEndian.PutUint32(mem.data[iovBase:], readBufPtr)
+	binary.LittleEndian.PutUint32(mem.data[iovBase+4:], uint32(len(data)))
+	if errno := wasiexec.ABIFDRead(mem, m, readFD, iovBase, 1, nreadPtr); errno != wasiexec.ErrnoSuccess {
+		t.Fatalf("ABIFDRead = %v, want success", errno)
+	}
+	if nread := binary.LittleEndian.Uint32(mem.data[nreadPtr:]); nread != uint32(len(data)) {
+		t.Fatalf("nread = %d, want %d", nread, len(data))
+	}
+	if got := string(mem.data[readBufPtr : readBufPtr+uint32(len(data))]); got != string(data) {
+		t.Fatalf("read content = %q, want %q", got, data)
+	}
+}
+
+func TestABIArgsGetAndEnvironSizesGet(t *testing.T) {
+	m := wasiexec.NewModule(hostfs.New(t.TempDir()), []string{"prog", "arg1"}, []string{"FOO=bar"})
+	mem := newFakeMemory(1 << 16)
+
+	const countPtr, sizePtr = 0, 4
+	if errno := wasiexec.ABIArgsSizesGet(mem, m, countPtr, sizePtr); errno != wasiexec.ErrnoSuccess {
+		t.Fatalf("ABIArgsSizesGet = %v, want success", errno)
+	}
+	count := binary.LittleEndian.Uint32(mem.data[countPtr:])
+	size := binary.LittleEndian.Uint32(mem.data[sizePtr:])
+	if count != 2 {
+		t.Fatalf("argc = %d, want 2", count)
+	}
+	if size != uint32(len("prog")+1+len("arg1")+1) {
+		t.Fatalf("args buffer size = %d, want %d", size, len("prog")+1+len("arg1")+1)
+	}
+
+	const ptrPtr, bufPtr = 100, 200
+	if errno := wasiexec.ABIArgsGet(mem, m, ptrPtr, bufPtr); errno != wasiexec.ErrnoSuccess {
+		t.Fatalf("ABIArgsGet = %v, want success", errno)
+	}
+	arg0Ptr := binary.LittleEndian.Uint32(mem.data[ptrPtr:])
+	if arg0Ptr != bufPtr {
+		t.Fatalf("argv[0] ptr = %d, want %d", arg0Ptr, bufPtr)
+	}
+	if got := cString(mem.data, arg0Ptr); got != "prog" {
+		t.Fatalf("argv[0] = %q, want %q", got, "prog")
+	}
+	arg1Ptr := binary.LittleEndian.Uint32(mem.data[ptrPtr+4:])
+	if got := cString(mem.data, arg1Ptr); got != "arg1" {
+		t.Fatalf("argv[1] = %q, want %q", got, "arg1")
+	}
+
+	const envCountPtr, envSizePtr = 300, 304
+	if errno := wasiexec.ABIEnvironSizesGet(mem, m, envCountPtr, envSizePtr); errno != wasiexec.ErrnoSuccess {
+		t.Fatalf("ABIEnvironSizesGet = %v, want success", errno)
+	}
+	if got := binary.LittleEndian.Uint32(mem.data[envCountPtr:]); got != 1 {
+		t.Fatalf("environc = %d, want 1", got)
+	}
+}
+
+func TestABIRandomGet(t *testing.T) {
+	m := wasiexec.NewModule(hostfs.New(t.TempDir()), nil, nil)
+	mem := newFakeMemory(64)
+
+	if errno := wasiexec.ABIRandomGet(mem, m, 0, 32); errno != wasiexec.ErrnoSuccess {
+		t.Fatalf("ABIRandomGet = %v, want success", errno)
+	}
+}
+
+// cString reads a NUL-terminated string out of data starting at offset, the
+// form putStringVector packs args/environ entries in.
+func cString(data []byte, offset uint32) string {
+	end := offset
+	for data[end] != 0 {
+		end++
+	}
+	return string(data[offset:end])
+}
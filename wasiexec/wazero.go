@@ -0,0 +1,156 @@
+package wasiexec
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// wazeroMemory adapts a wazero api.Memory to the Memory interface the ABI
+// layer in abi.go is written against.
+type wazeroMemory struct {
+	mem api.Memory
+}
+
+func (w wazeroMemory) Read(offset uint32, p []byte) error {
+	data, ok := w.mem.Read(offset, uint32(len(p)))
+	if !ok {
+		return ErrOutOfRange
+	}
+	copy(p, data)
+	return nil
+}
+
+func (w wazeroMemory) Write(offset uint32, p []byte) error {
+	if !w.mem.Write(offset, p) {
+		return ErrOutOfRange
+	}
+	return nil
+}
+
+// memoryOf returns the calling guest's linear memory as a Memory, the
+// first argument every host function below needs to decode its pointer
+// arguments.
+func memoryOf(mod api.Module) Memory {
+	return wazeroMemory{mem: mod.Memory()}
+}
+
+// Instantiate registers m's wasi_snapshot_preview1 imports on r and
+// instantiates them as a host module, so a guest compiled with
+// GOOS=wasip1 can be instantiated against r afterwards and resolve those
+// imports.
+func Instantiate(ctx context.Context, r wazero.Runtime, m *Module) error {
+	builder := r.NewHostModuleBuilder("wasi_snapshot_preview1")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, f int32, iovsPtr, iovsLen, nwrittenPtr uint32) uint32 {
+			return uint32(ABIFDWrite(memoryOf(mod), m, f, iovsPtr, iovsLen, nwrittenPtr))
+		}).
+		Export("fd_write")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, f int32, iovsPtr, iovsLen, nreadPtr uint32) uint32 {
+			return uint32(ABIFDRead(memoryOf(mod), m, f, iovsPtr, iovsLen, nreadPtr))
+		}).
+		Export("fd_read")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, f int32) uint32 {
+			return uint32(ABIFDClose(m, f))
+		}).
+		Export("fd_close")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, f int32, offset int64, whence uint32, newOffsetPtr uint32) uint32 {
+			return uint32(ABIFDSeek(memoryOf(mod), m, f, offset, uint8(whence), newOffsetPtr))
+		}).
+		Export("fd_seek")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, dirfd int32, oflags uint32, pathPtr, pathLen uint32, fdPtr uint32) uint32 {
+			return uint32(ABIPathOpen(memoryOf(mod), m, pathPtr, pathLen, OFlags(oflags), fdPtr))
+		}).
+		Export("path_open")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, dirfd int32, flags uint32, pathPtr, pathLen uint32, statPtr uint32) uint32 {
+			return uint32(ABIPathFilestatGet(memoryOf(mod), m, pathPtr, pathLen, statPtr))
+		}).
+		Export("path_filestat_get")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, dirfd int32, pathPtr, pathLen uint32) uint32 {
+			return uint32(ABIPathUnlinkFile(memoryOf(mod), m, pathPtr, pathLen))
+		}).
+		Export("path_unlink_file")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, dirfd int32, pathPtr, pathLen uint32) uint32 {
+			return uint32(ABIPathCreateDirectory(memoryOf(mod), m, pathPtr, pathLen))
+		}).
+		Export("path_create_directory")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, oldDirfd int32, oldPtr, oldLen uint32, newDirfd int32, newPtr, newLen uint32) uint32 {
+			return uint32(ABIPathRename(memoryOf(mod), m, oldPtr, oldLen, newPtr, newLen))
+		}).
+		Export("path_rename")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, oldPtr, oldLen uint32, newDirfd int32, newPtr, newLen uint32) uint32 {
+			return uint32(ABIPathSymlink(memoryOf(mod), m, oldPtr, oldLen, newPtr, newLen))
+		}).
+		Export("path_symlink")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, f int32, bufPtr, bufLen uint32, cookie uint64, bufUsedPtr uint32) uint32 {
+			return uint32(ABIFDReaddir(memoryOf(mod), m, f, bufPtr, bufLen, cookie, bufUsedPtr))
+		}).
+		Export("fd_readdir")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, id uint32, timePtr uint32) uint32 {
+			return uint32(ABIClockTimeGet(memoryOf(mod), m, timePtr))
+		}).
+		Export("clock_time_get")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, countPtr, sizePtr uint32) uint32 {
+			return uint32(ABIArgsSizesGet(memoryOf(mod), m, countPtr, sizePtr))
+		}).
+		Export("args_sizes_get")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, ptrPtr, bufPtr uint32) uint32 {
+			return uint32(ABIArgsGet(memoryOf(mod), m, ptrPtr, bufPtr))
+		}).
+		Export("args_get")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, countPtr, sizePtr uint32) uint32 {
+			return uint32(ABIEnvironSizesGet(memoryOf(mod), m, countPtr, sizePtr))
+		}).
+		Export("environ_sizes_get")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, ptrPtr, bufPtr uint32) uint32 {
+			return uint32(ABIEnvironGet(memoryOf(mod), m, ptrPtr, bufPtr))
+		}).
+		Export("environ_get")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, bufPtr, bufLen uint32) uint32 {
+			return uint32(ABIRandomGet(memoryOf(mod), m, bufPtr, bufLen))
+		}).
+		Export("random_get")
+
+	builder.NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, code uint32) {
+			_ = mod.CloseWithExitCode(ctx, ABIProcExit(m, code))
+		}).
+		Export("proc_exit")
+
+	_, err := builder.Instantiate(ctx)
+	return err
+}